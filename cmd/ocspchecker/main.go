@@ -1,30 +1,25 @@
-// Check OCSP revocation status.
+// Command ocspchecker checks OCSP revocation status.
 // 1. Get stapled response from tls conn.OCSPResponse()
-//      and check using ocsp.ParseResponse()
-// 2. If there is no stapled response, check manually:
-//      a. Get issuer and server x509 certs
-//      b. Get OCSP url from the server's x509 Certificate.OCSPServer
-//      c. Use ocsp.CreateRequest() to create a request
-//      d. Send POST request to {url} with raw ocsp request
+//      and check it with ocspchecker.CheckStapled()
+// 2. If there is no stapled response, check manually with
+//      ocspchecker.CheckOCSP(), which falls back to CRL checking if
+//      OCSP is unavailable.
 package main
 
 import (
-	"bytes"
 	"crypto/tls"
 	"crypto/x509"
 	"encoding/asn1"
 	"encoding/pem"
 	"flag"
 	"fmt"
-	"io"
 	"io/ioutil"
 	"log"
 	"net/http"
-	"os"
 	"strings"
 	"time"
 
-	"golang.org/x/crypto/ocsp"
+	"github.com/jcjones/ocspchecker"
 )
 
 var (
@@ -32,10 +27,8 @@ var (
 	certPath = flag.String("pem", "", "pem to check")
 	respURL  = flag.String("responder", "", "responder to use")
 	nostaple = flag.Bool("nostaple", false, "ignore staples")
-	dump = flag.Bool("dump", false, "dump raw bytes")
 
 	authorityInfoAccess = asn1.ObjectIdentifier{1, 3, 6, 1, 5, 5, 7, 1, 1}
-	aiaOCSP             = asn1.ObjectIdentifier{1, 3, 6, 1, 5, 5, 7, 48, 1}
 	aiaIssuer           = asn1.ObjectIdentifier{1, 3, 6, 1, 5, 5, 7, 48, 2}
 )
 
@@ -102,110 +95,47 @@ func grabServerCert(connState *tls.ConnectionState) *x509.Certificate {
 	return connState.VerifiedChains[0][0]
 }
 
-func manualCheck(ee *x509.Certificate, issuer *x509.Certificate) error {
-	var ocspURL string
-	if respURL != nil && *respURL != "" {
-		ocspURL = *respURL
-	} else {
-		ocspURL = ee.OCSPServer[0]
-	}
+func checkerOptions() *ocspchecker.Options {
+	return &ocspchecker.Options{ResponderURL: *respURL}
+}
 
+func printResult(ee, issuer *x509.Certificate, result *ocspchecker.Result) {
 	log.Printf("Server: %v\n", ee.Subject.CommonName)
 	log.Printf("Issuer: %v\n", issuer.Subject.CommonName)
-	log.Printf("OCSP URL: %v\n", ocspURL)
-
-	ocspReq, err := ocsp.CreateRequest(ee, issuer, nil)
-	if err != nil {
-		return fmt.Errorf("error creating ocsp request: %v", err)
-	}
-
-	if dump != nil && *dump {
-		block := &pem.Block{
-			Type: "OCSP Request",
-			Bytes: ocspReq,
-		}
-
-		if err := pem.Encode(os.Stdout, block); err != nil {
-			return err
-		}
-	}
-
-	body := bytes.NewReader(ocspReq)
-	req, err := http.NewRequest("POST", ocspURL, body)
-	if err != nil {
-		return fmt.Errorf("error creating http post request: %v", err)
+	if result.ResponderURL != "" {
+		log.Printf("Responder URL: %v\n", result.ResponderURL)
 	}
-	req.Header.Set("Content-Type", "application/ocsp-request")
-	client := &http.Client{}
-	resp, err := client.Do(req)
-	if err != nil {
-		return fmt.Errorf("error sending post request: %v", err)
+	log.Printf("Certificate Status %s.\n", result.Status)
+	if result.Status == ocspchecker.Revoked {
+		log.Printf("Reason: %s\n", revocationReasonString(result.RevocationReason))
 	}
-
-	defer resp.Body.Close()
-	buf := new(bytes.Buffer)
-	io.Copy(buf, resp.Body)
-	return parseResponse(buf.Bytes(), issuer)
 }
 
-func parseResponse(response []byte, issuer *x509.Certificate) error {
-	if dump != nil && *dump {
-		block := &pem.Block{
-			Type: "OCSP Response",
-			Bytes: response,
-		}
-
-		if err := pem.Encode(os.Stdout, block); err != nil {
-			return err
-		}
-	}
-
-	resp, err := ocsp.ParseResponse(response, issuer)
-	if err != nil {
-		return fmt.Errorf("error parsing response: %v", err)
-	}
-	if resp.Status == ocsp.Good {
-		log.Println("Certificate Status Good.")
-	} else if resp.Status == ocsp.Unknown {
-		log.Println("Certificate Status Unknown")
-	} else {
-		log.Println("Certificate Status Revoked")
-	}
-
-	var reason string
-	switch resp.RevocationReason {
+func revocationReasonString(reason int) string {
+	switch reason {
 	case 0:
-		reason = "Unspecified"
+		return "Unspecified"
 	case 1:
-		reason = "KeyCompromise"
+		return "KeyCompromise"
 	case 2:
-		reason = "CACompromise"
+		return "CACompromise"
 	case 3:
-		reason = "AffiliationChanged"
+		return "AffiliationChanged"
 	case 4:
-		reason = "Superseded"
+		return "Superseded"
 	case 5:
-		reason = "CessationOfOperation"
+		return "CessationOfOperation"
 	case 6:
-		reason = "CertificateHold"
+		return "CertificateHold"
 	case 8:
-		reason = "RemoveFromCRL"
+		return "RemoveFromCRL"
 	case 9:
-		reason = "PrivilegeWithdrawn"
+		return "PrivilegeWithdrawn"
 	case 10:
-		reason = "AACompromise"
+		return "AACompromise"
 	default:
-		reason = fmt.Sprintf("unexpected value: %d", resp.RevocationReason)
+		return fmt.Sprintf("unexpected value: %d", reason)
 	}
-	log.Printf("Reason: %s\n", reason)
-	return nil
-}
-
-func stapledCheck(ee *x509.Certificate, issuer *x509.Certificate, staple []byte) error {
-	log.Printf("Server: %v\n", ee.Subject.CommonName)
-	log.Printf("Issuer: %v\n", issuer.Subject.CommonName)
-
-	return parseResponse(staple, issuer)
 }
 
 func processURL() error {
@@ -222,15 +152,24 @@ func processURL() error {
 	issuer := grabIssuerCert(connState)
 	staple := connState.OCSPResponse
 
+	if staple == nil && ocspchecker.RequiresStapling(server) && !(nostaple != nil && *nostaple) {
+		return fmt.Errorf("certificate requires a stapled OCSP response (must-staple) but none was presented")
+	}
+
+	var result *ocspchecker.Result
 	if staple == nil || (nostaple != nil && *nostaple) {
-		// manually check revocation
 		log.Println("remote check")
-		return manualCheck(server, issuer)
+		result, err = ocspchecker.CheckOCSP(server, issuer, checkerOptions())
+	} else {
+		log.Println("stapled check")
+		result, err = ocspchecker.CheckStapled(server, issuer, staple, checkerOptions())
+	}
+	if err != nil {
+		return err
 	}
 
-	// parse the ocsp response
-	log.Println("stapled check")
-	return stapledCheck(server, issuer, staple)
+	printResult(server, issuer, result)
+	return nil
 }
 
 func processFile() error {
@@ -290,7 +229,13 @@ func processFile() error {
 		return err
 	}
 
-	return manualCheck(endEntity, fetchedCert)
+	result, err := ocspchecker.CheckOCSP(endEntity, fetchedCert, checkerOptions())
+	if err != nil {
+		return err
+	}
+
+	printResult(endEntity, fetchedCert, result)
+	return nil
 }
 
 func main() {
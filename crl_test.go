@@ -0,0 +1,235 @@
+package ocspchecker
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+// generateTestChainWithCRL is generateTestChain, extended with a leaf
+// certificate whose CRLDistributionPoints and, if deltaURL is set,
+// FreshestCRL extension point at the given URLs.
+func generateTestChainWithCRL(t *testing.T, crlURL, deltaURL string) (issuerCert *x509.Certificate, issuerKey *ecdsa.PrivateKey, eeCert *x509.Certificate) {
+	t.Helper()
+
+	issuerKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatalf("generating issuer key: %v", err)
+	}
+	issuerTemplate := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "Test Issuer"},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		KeyUsage:              x509.KeyUsageCertSign | x509.KeyUsageDigitalSignature,
+		BasicConstraintsValid: true,
+		IsCA:                  true,
+	}
+	issuerDER, err := x509.CreateCertificate(rand.Reader, issuerTemplate, issuerTemplate, &issuerKey.PublicKey, issuerKey)
+	if err != nil {
+		t.Fatalf("creating issuer certificate: %v", err)
+	}
+	issuerCert, err = x509.ParseCertificate(issuerDER)
+	if err != nil {
+		t.Fatalf("parsing issuer certificate: %v", err)
+	}
+
+	eeKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatalf("generating ee key: %v", err)
+	}
+	eeTemplate := &x509.Certificate{
+		SerialNumber:          big.NewInt(42),
+		Subject:               pkix.Name{CommonName: "Test Leaf"},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		KeyUsage:              x509.KeyUsageDigitalSignature,
+		CRLDistributionPoints: []string{crlURL},
+	}
+	if deltaURL != "" {
+		eeTemplate.ExtraExtensions = []pkix.Extension{
+			{Id: freshestCRL, Value: buildDistributionPointsExtension(deltaURL)},
+		}
+	}
+	eeDER, err := x509.CreateCertificate(rand.Reader, eeTemplate, issuerCert, &eeKey.PublicKey, issuerKey)
+	if err != nil {
+		t.Fatalf("creating ee certificate: %v", err)
+	}
+	eeCert, err = x509.ParseCertificate(eeDER)
+	if err != nil {
+		t.Fatalf("parsing ee certificate: %v", err)
+	}
+
+	return issuerCert, issuerKey, eeCert
+}
+
+func serveCRL(t *testing.T, issuer *x509.Certificate, issuerKey *ecdsa.PrivateKey, revoked []pkix.RevokedCertificate) *httptest.Server {
+	t.Helper()
+
+	der, err := issuer.CreateCRL(rand.Reader, issuerKey, revoked, time.Now().Add(-time.Minute), time.Now().Add(time.Hour))
+	if err != nil {
+		t.Fatalf("creating CRL: %v", err)
+	}
+
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write(der)
+	}))
+}
+
+func tlv(tag byte, content []byte) []byte {
+	return append([]byte{tag, byte(len(content))}, content...)
+}
+
+// buildDistributionPointsExtension constructs the DER encoding of a
+// CRLDistributionPoints-shaped extension (the shape FreshestCRL also
+// uses) with one DistributionPoint per url. Real certificates nest
+// the [6] URI inside two context-constructed [0] wrappers
+// (distributionPoint, then fullName); this mirrors that exactly.
+func buildDistributionPointsExtension(urls ...string) []byte {
+	var points []byte
+	for _, u := range urls {
+		uri := tlv(0x86, []byte(u))      // [6] IMPLICIT IA5String
+		fullName := tlv(0xA0, uri)       // fullName [0]
+		distPoint := tlv(0xA0, fullName) // distributionPoint [0]
+		points = append(points, tlv(0x30, distPoint)...)
+	}
+	return tlv(0x30, points)
+}
+
+func TestDecodeDistributionPointURLs(t *testing.T) {
+	ext := buildDistributionPointsExtension("http://example.com/delta.crl")
+
+	urls, err := decodeDistributionPointURLs(ext)
+	if err != nil {
+		t.Fatalf("decodeDistributionPointURLs: %v", err)
+	}
+	if len(urls) != 1 || urls[0] != "http://example.com/delta.crl" {
+		t.Fatalf("got %v, want [http://example.com/delta.crl]", urls)
+	}
+}
+
+func TestDecodeDistributionPointURLsMultiplePoints(t *testing.T) {
+	ext := buildDistributionPointsExtension("http://a.example.com/crl", "http://b.example.com/crl")
+
+	urls, err := decodeDistributionPointURLs(ext)
+	if err != nil {
+		t.Fatalf("decodeDistributionPointURLs: %v", err)
+	}
+	if len(urls) != 2 {
+		t.Fatalf("got %d urls, want 2: %v", len(urls), urls)
+	}
+}
+
+func TestMergeRevokedCertificates(t *testing.T) {
+	base := []pkix.RevokedCertificate{
+		{SerialNumber: big.NewInt(1)},
+		{SerialNumber: big.NewInt(2)},
+	}
+	delta := []pkix.RevokedCertificate{
+		{SerialNumber: big.NewInt(2)}, // supersedes base's entry for serial 2
+		{SerialNumber: big.NewInt(3)},
+	}
+
+	merged := mergeRevokedCertificates(base, delta)
+	if len(merged) != 3 {
+		t.Fatalf("got %d entries, want 3 (dedup on serial 2): %v", len(merged), merged)
+	}
+
+	seen := make(map[string]bool, len(merged))
+	for _, rc := range merged {
+		seen[rc.SerialNumber.String()] = true
+	}
+	for _, want := range []string{"1", "2", "3"} {
+		if !seen[want] {
+			t.Errorf("missing serial %s in merged list", want)
+		}
+	}
+}
+
+func TestCheckCRLGood(t *testing.T) {
+	issuer, issuerKey, ee := generateTestChainWithCRL(t, "placeholder", "")
+	server := serveCRL(t, issuer, issuerKey, nil)
+	defer server.Close()
+	ee.CRLDistributionPoints = []string{server.URL}
+
+	result, err := checkCRL(ee, issuer, &Options{})
+	if err != nil {
+		t.Fatalf("checkCRL: %v", err)
+	}
+	if result.Status != Good {
+		t.Errorf("got status %v, want Good", result.Status)
+	}
+	if result.ResponderURL != server.URL {
+		t.Errorf("got ResponderURL %q, want %q", result.ResponderURL, server.URL)
+	}
+}
+
+func TestCheckCRLRevoked(t *testing.T) {
+	issuer, issuerKey, ee := generateTestChainWithCRL(t, "placeholder", "")
+	server := serveCRL(t, issuer, issuerKey, []pkix.RevokedCertificate{
+		{SerialNumber: ee.SerialNumber, RevocationTime: time.Now()},
+	})
+	defer server.Close()
+	ee.CRLDistributionPoints = []string{server.URL}
+
+	result, err := checkCRL(ee, issuer, &Options{})
+	if err != nil {
+		t.Fatalf("checkCRL: %v", err)
+	}
+	if result.Status != Revoked {
+		t.Errorf("got status %v, want Revoked", result.Status)
+	}
+}
+
+func TestCheckCRLDeltaMerge(t *testing.T) {
+	issuer, issuerKey, ee := generateTestChainWithCRL(t, "placeholder", "placeholder")
+
+	baseServer := serveCRL(t, issuer, issuerKey, nil) // base CRL has no revocations
+	defer baseServer.Close()
+	deltaServer := serveCRL(t, issuer, issuerKey, []pkix.RevokedCertificate{
+		{SerialNumber: ee.SerialNumber, RevocationTime: time.Now()},
+	})
+	defer deltaServer.Close()
+
+	ee.CRLDistributionPoints = []string{baseServer.URL}
+	for i, ext := range ee.Extensions {
+		if ext.Id.Equal(freshestCRL) {
+			ee.Extensions[i].Value = buildDistributionPointsExtension(deltaServer.URL)
+		}
+	}
+
+	result, err := checkCRL(ee, issuer, &Options{})
+	if err != nil {
+		t.Fatalf("checkCRL: %v", err)
+	}
+	if result.Status != Revoked {
+		t.Errorf("got status %v, want Revoked from the delta CRL's entry", result.Status)
+	}
+}
+
+func TestCheckCRLNoDistributionPoints(t *testing.T) {
+	_, _, ee := generateTestChainWithCRL(t, "", "")
+	ee.CRLDistributionPoints = nil
+
+	if _, err := checkCRL(ee, nil, &Options{}); err == nil {
+		t.Fatal("expected an error when the certificate has no CRL distribution points")
+	}
+}
+
+func TestFetchCRLBadStatus(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNotFound)
+	}))
+	defer server.Close()
+
+	if _, err := fetchCRL(http.DefaultClient, server.URL); err == nil {
+		t.Fatal("expected an error for a non-200 CRL response")
+	}
+}
@@ -0,0 +1,169 @@
+package ocspchecker
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"errors"
+	"math/big"
+	"testing"
+	"time"
+
+	"golang.org/x/crypto/ocsp"
+)
+
+// buildDelegatedResponder issues a responder certificate from issuer,
+// carrying id-kp-OCSPSigning and, if includeNoCheck is set,
+// id-pkix-ocsp-nocheck.
+func buildDelegatedResponder(t *testing.T, issuer *x509.Certificate, issuerKey *ecdsa.PrivateKey, includeNoCheck, expired bool) (*x509.Certificate, *ecdsa.PrivateKey) {
+	t.Helper()
+
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatalf("generating responder key: %v", err)
+	}
+
+	notBefore := time.Now().Add(-time.Hour)
+	notAfter := time.Now().Add(time.Hour)
+	if expired {
+		notBefore = time.Now().Add(-2 * time.Hour)
+		notAfter = time.Now().Add(-time.Hour)
+	}
+
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(99),
+		Subject:      pkix.Name{CommonName: "Test Delegated Responder"},
+		NotBefore:    notBefore,
+		NotAfter:     notAfter,
+		KeyUsage:     x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageOCSPSigning},
+	}
+	if includeNoCheck {
+		template.ExtraExtensions = []pkix.Extension{{Id: ocspNoCheck, Value: []byte{0x05, 0x00}}}
+	}
+
+	der, err := x509.CreateCertificate(rand.Reader, template, issuer, &key.PublicKey, issuerKey)
+	if err != nil {
+		t.Fatalf("creating delegated responder certificate: %v", err)
+	}
+	cert, err := x509.ParseCertificate(der)
+	if err != nil {
+		t.Fatalf("parsing delegated responder certificate: %v", err)
+	}
+	return cert, key
+}
+
+func signDelegatedResponse(t *testing.T, ee, issuer, responder *x509.Certificate, responderKey *ecdsa.PrivateKey, status int) []byte {
+	t.Helper()
+
+	template := ocsp.Response{
+		Status:       status,
+		SerialNumber: ee.SerialNumber,
+		ThisUpdate:   time.Now().Add(-time.Minute),
+		NextUpdate:   time.Now().Add(time.Hour),
+		Certificate:  responder,
+	}
+	der, err := ocsp.CreateResponse(issuer, responder, template, responderKey)
+	if err != nil {
+		t.Fatalf("creating delegated ocsp response: %v", err)
+	}
+	return der
+}
+
+func TestVerifyOCSPResponseDelegatedResponder(t *testing.T) {
+	issuer, issuerKey, ee := generateTestChain(t)
+	responder, responderKey := buildDelegatedResponder(t, issuer, issuerKey, true, false)
+	respDER := signDelegatedResponse(t, ee, issuer, responder, responderKey, ocsp.Good)
+
+	resp, err := verifyOCSPResponse(respDER, issuer, nil)
+	if err != nil {
+		t.Fatalf("verifyOCSPResponse: %v", err)
+	}
+	if resp.Status != ocsp.Good {
+		t.Errorf("got status %v, want Good", resp.Status)
+	}
+}
+
+func TestVerifyOCSPResponseRejectsMissingNoCheck(t *testing.T) {
+	issuer, issuerKey, ee := generateTestChain(t)
+	responder, responderKey := buildDelegatedResponder(t, issuer, issuerKey, false, false)
+	respDER := signDelegatedResponse(t, ee, issuer, responder, responderKey, ocsp.Good)
+
+	_, err := verifyOCSPResponse(respDER, issuer, nil)
+	var rerr *ResponderError
+	if !errors.As(err, &rerr) || rerr.Kind != ErrResponderNotTrusted {
+		t.Fatalf("got err %v, want a ResponderError with Kind ErrResponderNotTrusted", err)
+	}
+}
+
+// buildSelfSignedCA returns a self-signed CA certificate and key,
+// unrelated to any issuer generateTestChain produces, for use as an
+// Options.ExtraCAs trust anchor.
+func buildSelfSignedCA(t *testing.T) (*x509.Certificate, *ecdsa.PrivateKey) {
+	t.Helper()
+
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatalf("generating CA key: %v", err)
+	}
+	template := &x509.Certificate{
+		SerialNumber:          big.NewInt(7),
+		Subject:               pkix.Name{CommonName: "Test Extra CA"},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		KeyUsage:              x509.KeyUsageCertSign | x509.KeyUsageDigitalSignature,
+		BasicConstraintsValid: true,
+		IsCA:                  true,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		t.Fatalf("creating CA certificate: %v", err)
+	}
+	cert, err := x509.ParseCertificate(der)
+	if err != nil {
+		t.Fatalf("parsing CA certificate: %v", err)
+	}
+	return cert, key
+}
+
+func TestVerifyOCSPResponseExtraCAs(t *testing.T) {
+	issuer, _, ee := generateTestChain(t)
+	extraCA, extraCAKey := buildSelfSignedCA(t)
+
+	template := ocsp.Response{
+		Status:       ocsp.Good,
+		SerialNumber: ee.SerialNumber,
+		ThisUpdate:   time.Now().Add(-time.Minute),
+		NextUpdate:   time.Now().Add(time.Hour),
+	}
+	respDER, err := ocsp.CreateResponse(extraCA, extraCA, template, extraCAKey)
+	if err != nil {
+		t.Fatalf("creating ocsp response: %v", err)
+	}
+
+	if _, err := verifyOCSPResponse(respDER, issuer, nil); err == nil {
+		t.Fatal("expected verification against issuer alone to fail for a response signed by an unrelated CA")
+	}
+
+	resp, err := verifyOCSPResponse(respDER, issuer, []*x509.Certificate{extraCA})
+	if err != nil {
+		t.Fatalf("verifyOCSPResponse with extraCAs: %v", err)
+	}
+	if resp.Status != ocsp.Good {
+		t.Errorf("got status %v, want Good", resp.Status)
+	}
+}
+
+func TestVerifyOCSPResponseRejectsExpiredResponder(t *testing.T) {
+	issuer, issuerKey, ee := generateTestChain(t)
+	responder, responderKey := buildDelegatedResponder(t, issuer, issuerKey, true, true)
+	respDER := signDelegatedResponse(t, ee, issuer, responder, responderKey, ocsp.Good)
+
+	_, err := verifyOCSPResponse(respDER, issuer, nil)
+	var rerr *ResponderError
+	if !errors.As(err, &rerr) || rerr.Kind != ErrResponderExpired {
+		t.Fatalf("got err %v, want a ResponderError with Kind ErrResponderExpired", err)
+	}
+}
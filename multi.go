@@ -0,0 +1,157 @@
+// Concurrent querying of a certificate's multiple OCSP responders.
+package ocspchecker
+
+import (
+	"crypto/x509"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+)
+
+// ResponderPolicy selects how results from multiple OCSP responders
+// are combined.
+type ResponderPolicy int
+
+const (
+	// FirstSuccess returns the fastest successful (Good or Revoked)
+	// response; the remaining responders are left to finish in the
+	// background.
+	FirstSuccess ResponderPolicy = iota
+	// QueryAllServers waits for every responder: any Revoked result
+	// fails the overall check, Unknown is only returned if every
+	// responder returned Unknown, and a responder is retried up to
+	// MaxRetries times with exponential backoff before being counted
+	// as failed.
+	QueryAllServers
+)
+
+type responderOutcome struct {
+	url    string
+	result *Result
+	err    error
+}
+
+// fetchOCSPMulti queries every URL in urls concurrently and combines
+// the results per opts.ResponderPolicy.
+func fetchOCSPMulti(ee, issuer *x509.Certificate, opts *Options, urls []string) (*Result, error) {
+	ch := make(chan responderOutcome, len(urls))
+	for _, u := range urls {
+		go func(url string) {
+			var result *Result
+			var err error
+			if opts.responderPolicy() == QueryAllServers {
+				result, err = fetchOCSPFromURLWithRetry(ee, issuer, opts, url)
+			} else {
+				result, err = fetchOCSPFromURL(ee, issuer, opts, url)
+			}
+			ch <- responderOutcome{url: url, result: result, err: err}
+		}(u)
+	}
+
+	if opts.responderPolicy() == QueryAllServers {
+		return combineQueryAllServers(ch, len(urls))
+	}
+	return combineFirstSuccess(ch, len(urls))
+}
+
+// combineFirstSuccess returns as soon as any responder succeeds. The
+// channel is buffered to len(urls), so the remaining goroutines can
+// still deliver their outcome without blocking or leaking.
+func combineFirstSuccess(ch <-chan responderOutcome, n int) (*Result, error) {
+	errs := make(map[string]error, n)
+
+	for i := 0; i < n; i++ {
+		o := <-ch
+		if o.err != nil {
+			errs[o.url] = o.err
+			continue
+		}
+		o.result.ResponderErrors = errs
+		return o.result, nil
+	}
+
+	return nil, combinedResponderError(errs)
+}
+
+// combineQueryAllServers implements the any-revoked-wins policy: a
+// single Revoked outcome fails the whole check, and Unknown is only
+// returned when every responder that answered returned Unknown.
+func combineQueryAllServers(ch <-chan responderOutcome, n int) (*Result, error) {
+	errs := make(map[string]error, n)
+	var revoked, good, unknown *Result
+
+	for i := 0; i < n; i++ {
+		o := <-ch
+		if o.err != nil {
+			errs[o.url] = o.err
+			continue
+		}
+		switch o.result.Status {
+		case Revoked:
+			revoked = o.result
+		case Good:
+			good = o.result
+		case Unknown:
+			unknown = o.result
+		}
+	}
+
+	switch {
+	case revoked != nil:
+		revoked.ResponderErrors = errs
+		return revoked, nil
+	case good != nil:
+		good.ResponderErrors = errs
+		return good, nil
+	case unknown != nil:
+		unknown.ResponderErrors = errs
+		return unknown, nil
+	default:
+		return nil, combinedResponderError(errs)
+	}
+}
+
+func fetchOCSPFromURLWithRetry(ee, issuer *x509.Certificate, opts *Options, url string) (*Result, error) {
+	var lastErr error
+	retries := opts.maxRetries()
+
+	for attempt := 0; attempt <= retries; attempt++ {
+		if attempt > 0 {
+			time.Sleep(retryBackoff(attempt))
+		}
+		result, err := fetchOCSPFromURL(ee, issuer, opts, url)
+		if err == nil {
+			return result, nil
+		}
+		lastErr = err
+	}
+
+	return nil, lastErr
+}
+
+func retryBackoff(attempt int) time.Duration {
+	return (1 << uint(attempt-1)) * 200 * time.Millisecond
+}
+
+// combinedResponderError aggregates the per-URL errors from a failed
+// multi-responder query into a single error, so a caller isn't left
+// with only one arbitrary responder's failure reason.
+func combinedResponderError(errs map[string]error) error {
+	if len(errs) == 0 {
+		return nil
+	}
+
+	urls := make([]string, 0, len(errs))
+	for url := range errs {
+		urls = append(urls, url)
+	}
+	sort.Strings(urls)
+
+	parts := make([]string, 0, len(urls))
+	for _, url := range urls {
+		parts = append(parts, fmt.Sprintf("%s: %v", url, errs[url]))
+	}
+
+	return fmt.Errorf("ocspchecker: all %d OCSP responders failed: %s", len(urls), strings.Join(parts, "; "))
+}
@@ -0,0 +1,94 @@
+package ocspchecker
+
+import (
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestCombineFirstSuccess(t *testing.T) {
+	ch := make(chan responderOutcome, 2)
+	ch <- responderOutcome{url: "https://a.example.com", err: errors.New("timeout")}
+	ch <- responderOutcome{url: "https://b.example.com", result: &Result{Status: Good}}
+
+	result, err := combineFirstSuccess(ch, 2)
+	if err != nil {
+		t.Fatalf("combineFirstSuccess: %v", err)
+	}
+	if result.Status != Good {
+		t.Errorf("got status %v, want Good", result.Status)
+	}
+	if len(result.ResponderErrors) != 1 || result.ResponderErrors["https://a.example.com"] == nil {
+		t.Errorf("expected the failed responder's error to be recorded, got %v", result.ResponderErrors)
+	}
+}
+
+func TestCombineFirstSuccessAllFail(t *testing.T) {
+	ch := make(chan responderOutcome, 2)
+	ch <- responderOutcome{url: "https://a.example.com", err: errors.New("timeout")}
+	ch <- responderOutcome{url: "https://b.example.com", err: errors.New("connection refused")}
+
+	_, err := combineFirstSuccess(ch, 2)
+	if err == nil {
+		t.Fatal("expected an error when every responder fails")
+	}
+	if !strings.Contains(err.Error(), "timeout") || !strings.Contains(err.Error(), "connection refused") {
+		t.Errorf("expected the aggregated error to mention every responder's failure, got %q", err)
+	}
+}
+
+func TestCombineQueryAllServersAnyRevokedWins(t *testing.T) {
+	ch := make(chan responderOutcome, 2)
+	ch <- responderOutcome{url: "https://a.example.com", result: &Result{Status: Good}}
+	ch <- responderOutcome{url: "https://b.example.com", result: &Result{Status: Revoked}}
+
+	result, err := combineQueryAllServers(ch, 2)
+	if err != nil {
+		t.Fatalf("combineQueryAllServers: %v", err)
+	}
+	if result.Status != Revoked {
+		t.Errorf("got status %v, want Revoked", result.Status)
+	}
+}
+
+func TestCombineQueryAllServersGoodBeatsUnknown(t *testing.T) {
+	ch := make(chan responderOutcome, 2)
+	ch <- responderOutcome{url: "https://a.example.com", result: &Result{Status: Unknown}}
+	ch <- responderOutcome{url: "https://b.example.com", result: &Result{Status: Good}}
+
+	result, err := combineQueryAllServers(ch, 2)
+	if err != nil {
+		t.Fatalf("combineQueryAllServers: %v", err)
+	}
+	if result.Status != Good {
+		t.Errorf("got status %v, want Good when at least one responder is Good", result.Status)
+	}
+}
+
+func TestCombineQueryAllServersUnknownOnlyIfAllUnknown(t *testing.T) {
+	ch := make(chan responderOutcome, 2)
+	ch <- responderOutcome{url: "https://a.example.com", result: &Result{Status: Unknown}}
+	ch <- responderOutcome{url: "https://b.example.com", result: &Result{Status: Unknown}}
+
+	result, err := combineQueryAllServers(ch, 2)
+	if err != nil {
+		t.Fatalf("combineQueryAllServers: %v", err)
+	}
+	if result.Status != Unknown {
+		t.Errorf("got status %v, want Unknown", result.Status)
+	}
+}
+
+func TestCombineQueryAllServersAllFail(t *testing.T) {
+	ch := make(chan responderOutcome, 2)
+	ch <- responderOutcome{url: "https://a.example.com", err: errors.New("timeout")}
+	ch <- responderOutcome{url: "https://b.example.com", err: errors.New("connection refused")}
+
+	_, err := combineQueryAllServers(ch, 2)
+	if err == nil {
+		t.Fatal("expected an error when every responder fails")
+	}
+	if !strings.Contains(err.Error(), "timeout") || !strings.Contains(err.Error(), "connection refused") {
+		t.Errorf("expected the aggregated error to mention every responder's failure, got %q", err)
+	}
+}
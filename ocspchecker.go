@@ -0,0 +1,236 @@
+// Package ocspchecker checks the revocation status of X.509
+// certificates. It supports parsing stapled OCSP responses, issuing
+// OCSP requests directly against a responder, and falling back to the
+// certificate's CRL distribution points when OCSP is unavailable or
+// inconclusive.
+package ocspchecker
+
+import (
+	"crypto/x509"
+	"fmt"
+	"net/http"
+	"time"
+)
+
+// Status is the revocation status of a certificate, mirroring the
+// values used by golang.org/x/crypto/ocsp.
+type Status int
+
+const (
+	Good Status = iota
+	Revoked
+	Unknown
+)
+
+func (s Status) String() string {
+	switch s {
+	case Good:
+		return "Good"
+	case Revoked:
+		return "Revoked"
+	case Unknown:
+		return "Unknown"
+	default:
+		return fmt.Sprintf("Status(%d)", int(s))
+	}
+}
+
+// Mode selects which revocation checking mechanisms CheckOCSP and
+// CheckChain are allowed to use.
+type Mode int
+
+const (
+	// ModeOCSPWithCRLFallback queries OCSP first and falls back to a
+	// CRL check if OCSP is unreachable or returns an Unknown status.
+	// It is the zero value of Mode, so a zero-value or nil Options
+	// gets this behavior.
+	ModeOCSPWithCRLFallback Mode = iota
+	// ModeOCSPOnly queries OCSP responders exclusively.
+	ModeOCSPOnly
+	// ModeCRLOnly checks revocation against the certificate's CRL
+	// distribution points exclusively.
+	ModeCRLOnly
+)
+
+// Result is the outcome of a revocation check.
+type Result struct {
+	Status           Status
+	RevocationReason int
+	ThisUpdate       time.Time
+	NextUpdate       time.Time
+	// ResponderURL is the OCSP responder or CRL distribution point that
+	// produced this Result.
+	ResponderURL string
+	// ResponderErrors holds the error returned by each responder URL
+	// that failed during a multi-responder query, keyed by URL. It is
+	// only populated by CheckOCSP when ee advertises more than one
+	// OCSP responder.
+	ResponderErrors map[string]error
+}
+
+// Options controls how CheckOCSP and CheckChain perform revocation
+// checks. A nil *Options is equivalent to the zero value.
+type Options struct {
+	// ResponderURL overrides the OCSP responder URL advertised by the
+	// certificate's AuthorityInfoAccess extension.
+	ResponderURL string
+	// Mode selects OCSP-only, CRL-only, or OCSP-with-CRL-fallback
+	// checking. Defaults to ModeOCSPWithCRLFallback.
+	Mode Mode
+	// DisableGETPreference turns off the RFC 5019 behavior of issuing
+	// OCSP requests of 255 bytes or less as a GET instead of a POST.
+	// GET is preferred by default, falling back to POST on failure;
+	// the zero value of Options therefore still prefers GET, so set
+	// this to true to opt out.
+	DisableGETPreference bool
+	// HTTPClient is used for OCSP and CRL requests. Defaults to
+	// http.DefaultClient.
+	HTTPClient *http.Client
+	// Source, if set, is consulted for a cached OCSP response before
+	// any network request is made, and is populated with responses
+	// fetched over the network.
+	Source Source
+	// ResponderPolicy selects how results are combined when a
+	// certificate advertises more than one OCSP responder. Defaults to
+	// FirstSuccess.
+	ResponderPolicy ResponderPolicy
+	// ResponderTimeout bounds each individual responder request when
+	// querying more than one OCSP responder. Defaults to
+	// HTTPClient's own timeout.
+	ResponderTimeout time.Duration
+	// MaxRetries is the number of additional attempts, with
+	// exponential backoff, made against a failing responder under
+	// QueryAllServers. Defaults to 0 (no retries).
+	MaxRetries int
+	// ExtraCAs are additional trust anchors tried, after issuer and
+	// any delegated responder certificate embedded in the response,
+	// when verifying an OCSP response's signature.
+	ExtraCAs []*x509.Certificate
+	// MaxStaleness is how old a stapled response's ThisUpdate may be
+	// before CheckStapled rejects it. Defaults to 7 days.
+	MaxStaleness time.Duration
+}
+
+// defaultMaxStaleness is the MaxStaleness applied when Options is nil
+// or MaxStaleness is unset.
+const defaultMaxStaleness = 7 * 24 * time.Hour
+
+func (o *Options) httpClient() *http.Client {
+	if o != nil && o.HTTPClient != nil {
+		return o.HTTPClient
+	}
+	return http.DefaultClient
+}
+
+func (o *Options) responderURL(ee *x509.Certificate) (string, error) {
+	if o != nil && o.ResponderURL != "" {
+		return o.ResponderURL, nil
+	}
+	if len(ee.OCSPServer) == 0 {
+		return "", fmt.Errorf("ocspchecker: certificate has no OCSP responder")
+	}
+	return ee.OCSPServer[0], nil
+}
+
+func (o *Options) mode() Mode {
+	if o == nil {
+		return ModeOCSPWithCRLFallback
+	}
+	return o.Mode
+}
+
+func (o *Options) preferGET() bool {
+	if o == nil {
+		return true
+	}
+	return !o.DisableGETPreference
+}
+
+// responderClient returns httpClient with ResponderTimeout applied,
+// for use when querying a single OCSP responder URL.
+func (o *Options) responderClient() *http.Client {
+	client := o.httpClient()
+	if o == nil || o.ResponderTimeout <= 0 {
+		return client
+	}
+	clone := *client
+	clone.Timeout = o.ResponderTimeout
+	return &clone
+}
+
+func (o *Options) responderPolicy() ResponderPolicy {
+	if o == nil {
+		return FirstSuccess
+	}
+	return o.ResponderPolicy
+}
+
+func (o *Options) maxRetries() int {
+	if o == nil {
+		return 0
+	}
+	return o.MaxRetries
+}
+
+func (o *Options) extraCAs() []*x509.Certificate {
+	if o == nil {
+		return nil
+	}
+	return o.ExtraCAs
+}
+
+func (o *Options) maxStaleness() time.Duration {
+	if o == nil || o.MaxStaleness <= 0 {
+		return defaultMaxStaleness
+	}
+	return o.MaxStaleness
+}
+
+// CheckStapled parses a stapled OCSP response for ee, signed by
+// issuer, rejecting it if its serial does not match ee or its
+// ThisUpdate is older than opts.MaxStaleness. A nil opts behaves as
+// MaxStaleness of 7 days.
+func CheckStapled(ee, issuer *x509.Certificate, staple []byte, opts *Options) (*Result, error) {
+	resp, err := verifyOCSPResponse(staple, issuer, opts.extraCAs())
+	if err != nil {
+		return nil, err
+	}
+
+	if resp.SerialNumber.Cmp(ee.SerialNumber) != 0 {
+		return nil, fmt.Errorf("ocspchecker: stapled response serial does not match served certificate")
+	}
+
+	if age := time.Since(resp.ThisUpdate); age > opts.maxStaleness() {
+		return nil, fmt.Errorf("ocspchecker: stapled response is %s old, exceeds MaxStaleness of %s", age, opts.maxStaleness())
+	}
+
+	return resultFromResponse(resp), nil
+}
+
+// CheckOCSP checks the revocation status of ee, whose issuer is
+// issuer, using the mechanism selected by opts.Mode. A nil opts
+// behaves as ModeOCSPWithCRLFallback against http.DefaultClient.
+func CheckOCSP(ee, issuer *x509.Certificate, opts *Options) (*Result, error) {
+	switch opts.mode() {
+	case ModeCRLOnly:
+		return checkCRL(ee, issuer, opts)
+	case ModeOCSPWithCRLFallback:
+		result, err := fetchOCSP(ee, issuer, opts)
+		if err == nil && result.Status != Unknown {
+			return result, nil
+		}
+		return checkCRL(ee, issuer, opts)
+	default:
+		return fetchOCSP(ee, issuer, opts)
+	}
+}
+
+// CheckChain checks the revocation status of chain[0] against its
+// issuer chain[1], using the mechanism selected by opts.Mode. chain
+// must contain at least the end-entity certificate and its issuer.
+func CheckChain(chain []*x509.Certificate, opts *Options) (*Result, error) {
+	if len(chain) < 2 {
+		return nil, fmt.Errorf("ocspchecker: chain must contain at least an end-entity and issuer certificate")
+	}
+	return CheckOCSP(chain[0], chain[1], opts)
+}
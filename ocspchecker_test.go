@@ -0,0 +1,145 @@
+package ocspchecker
+
+import (
+	"crypto/x509"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"golang.org/x/crypto/ocsp"
+)
+
+func serveOCSP(t *testing.T, respDER []byte) *httptest.Server {
+	t.Helper()
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/ocsp-response")
+		w.Write(respDER)
+	}))
+}
+
+func TestCheckOCSPModeOCSPOnly(t *testing.T) {
+	issuer, issuerKey, ee := generateTestChain(t)
+	ocspServer := serveOCSP(t, signTestOCSPResponse(t, ee, issuer, issuerKey, ocsp.Good))
+	defer ocspServer.Close()
+
+	result, err := CheckOCSP(ee, issuer, &Options{ResponderURL: ocspServer.URL, Mode: ModeOCSPOnly})
+	if err != nil {
+		t.Fatalf("CheckOCSP: %v", err)
+	}
+	if result.Status != Good {
+		t.Errorf("got status %v, want Good", result.Status)
+	}
+}
+
+func TestCheckOCSPModeCRLOnly(t *testing.T) {
+	issuer, issuerKey, ee := generateTestChainWithCRL(t, "placeholder", "")
+	crlServer := serveCRL(t, issuer, issuerKey, nil)
+	defer crlServer.Close()
+	ee.CRLDistributionPoints = []string{crlServer.URL}
+
+	result, err := CheckOCSP(ee, issuer, &Options{Mode: ModeCRLOnly})
+	if err != nil {
+		t.Fatalf("CheckOCSP: %v", err)
+	}
+	if result.Status != Good {
+		t.Errorf("got status %v, want Good", result.Status)
+	}
+}
+
+func TestCheckOCSPFallsBackToCRLOnUnknown(t *testing.T) {
+	issuer, issuerKey, ee := generateTestChainWithCRL(t, "placeholder", "")
+	ocspServer := serveOCSP(t, signTestOCSPResponse(t, ee, issuer, issuerKey, ocsp.Unknown))
+	defer ocspServer.Close()
+	crlServer := serveCRL(t, issuer, issuerKey, nil)
+	defer crlServer.Close()
+	ee.CRLDistributionPoints = []string{crlServer.URL}
+
+	result, err := CheckOCSP(ee, issuer, &Options{ResponderURL: ocspServer.URL})
+	if err != nil {
+		t.Fatalf("CheckOCSP: %v", err)
+	}
+	if result.ResponderURL != crlServer.URL {
+		t.Errorf("got ResponderURL %q, want the CRL server %q (expected a CRL fallback)", result.ResponderURL, crlServer.URL)
+	}
+	if result.Status != Good {
+		t.Errorf("got status %v, want Good", result.Status)
+	}
+}
+
+func TestCheckOCSPFallsBackToCRLOnError(t *testing.T) {
+	issuer, issuerKey, ee := generateTestChainWithCRL(t, "placeholder", "")
+
+	deadOCSPServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusInternalServerError)
+	}))
+	deadOCSPServer.Close() // closed immediately, so requests fail to connect
+
+	crlServer := serveCRL(t, issuer, issuerKey, nil)
+	defer crlServer.Close()
+	ee.CRLDistributionPoints = []string{crlServer.URL}
+
+	result, err := CheckOCSP(ee, issuer, &Options{ResponderURL: deadOCSPServer.URL})
+	if err != nil {
+		t.Fatalf("CheckOCSP: %v", err)
+	}
+	if result.ResponderURL != crlServer.URL {
+		t.Errorf("got ResponderURL %q, want the CRL server %q (expected a CRL fallback)", result.ResponderURL, crlServer.URL)
+	}
+}
+
+func TestCheckChainRejectsShortChain(t *testing.T) {
+	_, _, ee := generateTestChain(t)
+
+	if _, err := CheckChain([]*x509.Certificate{ee}, nil); err == nil {
+		t.Fatal("expected an error for a chain with no issuer")
+	}
+}
+
+func TestCheckChainDelegatesToCheckOCSP(t *testing.T) {
+	issuer, issuerKey, ee := generateTestChain(t)
+	ocspServer := serveOCSP(t, signTestOCSPResponse(t, ee, issuer, issuerKey, ocsp.Good))
+	defer ocspServer.Close()
+
+	result, err := CheckChain([]*x509.Certificate{ee, issuer}, &Options{ResponderURL: ocspServer.URL, Mode: ModeOCSPOnly})
+	if err != nil {
+		t.Fatalf("CheckChain: %v", err)
+	}
+	if result.Status != Good {
+		t.Errorf("got status %v, want Good", result.Status)
+	}
+}
+
+func TestCheckStapledSucceeds(t *testing.T) {
+	issuer, issuerKey, ee := generateTestChain(t)
+	staple := signTestOCSPResponse(t, ee, issuer, issuerKey, ocsp.Good)
+
+	result, err := CheckStapled(ee, issuer, staple, nil)
+	if err != nil {
+		t.Fatalf("CheckStapled: %v", err)
+	}
+	if result.Status != Good {
+		t.Errorf("got status %v, want Good", result.Status)
+	}
+}
+
+func TestCheckStapledRejectsStaleResponse(t *testing.T) {
+	issuer, issuerKey, ee := generateTestChain(t)
+	staple := signTestOCSPResponse(t, ee, issuer, issuerKey, ocsp.Good)
+
+	_, err := CheckStapled(ee, issuer, staple, &Options{MaxStaleness: time.Second})
+	if err == nil {
+		t.Fatal("expected an error for a staple older than MaxStaleness")
+	}
+}
+
+func TestCheckStapledRejectsSerialMismatch(t *testing.T) {
+	issuer, issuerKey, ee := generateTestChain(t)
+	staple := signTestOCSPResponse(t, ee, issuer, issuerKey, ocsp.Good)
+
+	otherEE := &x509.Certificate{SerialNumber: big.NewInt(ee.SerialNumber.Int64() + 1)}
+	if _, err := CheckStapled(otherEE, issuer, staple, nil); err == nil {
+		t.Fatal("expected an error when the staple's serial does not match the served certificate")
+	}
+}
@@ -0,0 +1,169 @@
+// OCSP request construction and response parsing.
+package ocspchecker
+
+import (
+	"bytes"
+	"crypto/x509"
+	"encoding/base64"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"strings"
+	"time"
+
+	"golang.org/x/crypto/ocsp"
+)
+
+// maxGETRequestSize is the largest DER-encoded OCSP request that may
+// be sent via GET, per RFC 5019 section 5.
+const maxGETRequestSize = 255
+
+// base64PathEscaper percent-encodes the characters standard base64 can
+// produce that are not safe to place unescaped in a URL path segment,
+// per RFC 5019 section 5: '+' and '/' are reserved path delimiters and
+// '=' is padding that some responders misinterpret.
+var base64PathEscaper = strings.NewReplacer("+", "%2B", "/", "%2F", "=", "%3D")
+
+func fetchOCSP(ee, issuer *x509.Certificate, opts *Options) (*Result, error) {
+	if result, ok := checkSource(ee, issuer, opts); ok {
+		return result, nil
+	}
+	return fetchOCSPFresh(ee, issuer, opts)
+}
+
+// fetchOCSPFresh always queries the network, bypassing opts.Source. If
+// ee advertises more than one OCSP responder and opts.ResponderURL
+// does not pin a single one, all responders are queried per
+// opts.ResponderPolicy.
+func fetchOCSPFresh(ee, issuer *x509.Certificate, opts *Options) (*Result, error) {
+	if opts == nil || opts.ResponderURL == "" {
+		if len(ee.OCSPServer) > 1 {
+			return fetchOCSPMulti(ee, issuer, opts, ee.OCSPServer)
+		}
+	}
+
+	ocspURL, err := opts.responderURL(ee)
+	if err != nil {
+		return nil, err
+	}
+	return fetchOCSPFromURL(ee, issuer, opts, ocspURL)
+}
+
+// fetchOCSPFromURL queries a single responder URL, preferring GET for
+// small requests and falling back to POST.
+func fetchOCSPFromURL(ee, issuer *x509.Certificate, opts *Options, responderURL string) (*Result, error) {
+	ocspReq, err := ocsp.CreateRequest(ee, issuer, nil)
+	if err != nil {
+		return nil, fmt.Errorf("ocspchecker: error creating ocsp request: %v", err)
+	}
+
+	client := opts.responderClient()
+
+	if opts.preferGET() && len(ocspReq) <= maxGETRequestSize {
+		result, err := getOCSP(client, responderURL, ocspReq, issuer, opts)
+		if err == nil {
+			return result, nil
+		}
+	}
+
+	return postOCSP(client, responderURL, ocspReq, issuer, opts)
+}
+
+// getOCSP issues the request as a GET, per RFC 2560/5019: the DER
+// request is base64-encoded and appended to the responder URL path,
+// which lets caching intermediaries serve the response.
+func getOCSP(client *http.Client, responderURL string, ocspReq []byte, issuer *x509.Certificate, opts *Options) (*Result, error) {
+	encoded := base64PathEscaper.Replace(base64.StdEncoding.EncodeToString(ocspReq))
+	getURL := strings.TrimSuffix(responderURL, "/") + "/" + encoded
+
+	req, err := http.NewRequest("GET", getURL, nil)
+	if err != nil {
+		return nil, fmt.Errorf("ocspchecker: error creating http request: %v", err)
+	}
+	req.Header.Set("Accept", "application/ocsp-response")
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("ocspchecker: error sending ocsp request: %v", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return nil, fmt.Errorf("ocspchecker: unexpected status from GET ocsp request: %s", resp.Status)
+	}
+
+	return finishOCSP(resp, responderURL, issuer, opts)
+}
+
+func postOCSP(client *http.Client, responderURL string, ocspReq []byte, issuer *x509.Certificate, opts *Options) (*Result, error) {
+	req, err := http.NewRequest("POST", responderURL, bytes.NewReader(ocspReq))
+	if err != nil {
+		return nil, fmt.Errorf("ocspchecker: error creating http request: %v", err)
+	}
+	req.Header.Set("Content-Type", "application/ocsp-request")
+	req.Header.Set("Accept", "application/ocsp-response")
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("ocspchecker: error sending ocsp request: %v", err)
+	}
+	defer resp.Body.Close()
+
+	return finishOCSP(resp, responderURL, issuer, opts)
+}
+
+func finishOCSP(resp *http.Response, responderURL string, issuer *x509.Certificate, opts *Options) (*Result, error) {
+	body, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return nil, fmt.Errorf("ocspchecker: error reading ocsp response: %v", err)
+	}
+
+	parsed, err := verifyOCSPResponse(body, issuer, opts.extraCAs())
+	if err != nil {
+		return nil, err
+	}
+
+	if opts != nil && opts.Source != nil {
+		opts.Source.Put(parsed.SerialNumber, body)
+	}
+
+	result := resultFromResponse(parsed)
+	result.ResponderURL = responderURL
+	return result, nil
+}
+
+// checkSource returns the cached Result for ee from opts.Source, if
+// one is present, valid for issuer, and not yet stale.
+func checkSource(ee, issuer *x509.Certificate, opts *Options) (*Result, bool) {
+	if opts == nil || opts.Source == nil {
+		return nil, false
+	}
+
+	der, ok := opts.Source.Get(ee.SerialNumber)
+	if !ok {
+		return nil, false
+	}
+
+	resp, err := verifyOCSPResponse(der, issuer, opts.extraCAs())
+	if err != nil {
+		return nil, false
+	}
+
+	now := time.Now()
+	if now.Before(resp.ThisUpdate) || now.After(resp.NextUpdate) {
+		return nil, false
+	}
+
+	result := resultFromResponse(resp)
+	result.ResponderURL = "cache"
+	return result, true
+}
+
+func resultFromResponse(resp *ocsp.Response) *Result {
+	return &Result{
+		Status:           Status(resp.Status),
+		RevocationReason: resp.RevocationReason,
+		ThisUpdate:       resp.ThisUpdate,
+		NextUpdate:       resp.NextUpdate,
+	}
+}
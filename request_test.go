@@ -0,0 +1,170 @@
+package ocspchecker
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"golang.org/x/crypto/ocsp"
+)
+
+// generateTestChain returns a self-signed issuer and a leaf certificate
+// it issued, for use as fetchOCSP/verifyOCSPResponse inputs in tests.
+func generateTestChain(t *testing.T) (issuerCert *x509.Certificate, issuerKey *ecdsa.PrivateKey, eeCert *x509.Certificate) {
+	t.Helper()
+
+	issuerKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatalf("generating issuer key: %v", err)
+	}
+	issuerTemplate := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "Test Issuer"},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		KeyUsage:              x509.KeyUsageCertSign | x509.KeyUsageDigitalSignature,
+		BasicConstraintsValid: true,
+		IsCA:                  true,
+	}
+	issuerDER, err := x509.CreateCertificate(rand.Reader, issuerTemplate, issuerTemplate, &issuerKey.PublicKey, issuerKey)
+	if err != nil {
+		t.Fatalf("creating issuer certificate: %v", err)
+	}
+	issuerCert, err = x509.ParseCertificate(issuerDER)
+	if err != nil {
+		t.Fatalf("parsing issuer certificate: %v", err)
+	}
+
+	eeKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatalf("generating ee key: %v", err)
+	}
+	eeTemplate := &x509.Certificate{
+		SerialNumber: big.NewInt(42),
+		Subject:      pkix.Name{CommonName: "Test Leaf"},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		KeyUsage:     x509.KeyUsageDigitalSignature,
+	}
+	eeDER, err := x509.CreateCertificate(rand.Reader, eeTemplate, issuerCert, &eeKey.PublicKey, issuerKey)
+	if err != nil {
+		t.Fatalf("creating ee certificate: %v", err)
+	}
+	eeCert, err = x509.ParseCertificate(eeDER)
+	if err != nil {
+		t.Fatalf("parsing ee certificate: %v", err)
+	}
+
+	return issuerCert, issuerKey, eeCert
+}
+
+func signTestOCSPResponse(t *testing.T, ee, issuer *x509.Certificate, issuerKey *ecdsa.PrivateKey, status int) []byte {
+	t.Helper()
+
+	template := ocsp.Response{
+		Status:       status,
+		SerialNumber: ee.SerialNumber,
+		ThisUpdate:   time.Now().Add(-time.Minute),
+		NextUpdate:   time.Now().Add(time.Hour),
+	}
+	der, err := ocsp.CreateResponse(issuer, issuer, template, issuerKey)
+	if err != nil {
+		t.Fatalf("creating ocsp response: %v", err)
+	}
+	return der
+}
+
+func TestFetchOCSPGetSucceeds(t *testing.T) {
+	issuer, issuerKey, ee := generateTestChain(t)
+	respDER := signTestOCSPResponse(t, ee, issuer, issuerKey, ocsp.Good)
+
+	var sawGET, sawPOST bool
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.Method {
+		case http.MethodGet:
+			sawGET = true
+		case http.MethodPost:
+			sawPOST = true
+		}
+		w.Header().Set("Content-Type", "application/ocsp-response")
+		w.Write(respDER)
+	}))
+	defer server.Close()
+
+	result, err := fetchOCSP(ee, issuer, &Options{ResponderURL: server.URL})
+	if err != nil {
+		t.Fatalf("fetchOCSP: %v", err)
+	}
+	if !sawGET {
+		t.Error("expected a GET request for a small OCSP request")
+	}
+	if sawPOST {
+		t.Error("did not expect a POST fallback when GET succeeds")
+	}
+	if result.Status != Good {
+		t.Errorf("got status %v, want Good", result.Status)
+	}
+}
+
+func TestFetchOCSPFallsBackToPOSTWhenGETFails(t *testing.T) {
+	issuer, issuerKey, ee := generateTestChain(t)
+	respDER := signTestOCSPResponse(t, ee, issuer, issuerKey, ocsp.Good)
+
+	var sawGET, sawPOST bool
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.Method {
+		case http.MethodGet:
+			sawGET = true
+			w.WriteHeader(http.StatusNotFound)
+		case http.MethodPost:
+			sawPOST = true
+			w.Header().Set("Content-Type", "application/ocsp-response")
+			w.Write(respDER)
+		}
+	}))
+	defer server.Close()
+
+	result, err := fetchOCSP(ee, issuer, &Options{ResponderURL: server.URL})
+	if err != nil {
+		t.Fatalf("fetchOCSP: %v", err)
+	}
+	if !sawGET {
+		t.Error("expected a GET request for a small OCSP request")
+	}
+	if !sawPOST {
+		t.Error("expected a POST fallback after the GET failed")
+	}
+	if result.Status != Good {
+		t.Errorf("got status %v, want Good", result.Status)
+	}
+}
+
+func TestFetchOCSPSkipsGETWhenDisableGETPreferenceSet(t *testing.T) {
+	issuer, issuerKey, ee := generateTestChain(t)
+	respDER := signTestOCSPResponse(t, ee, issuer, issuerKey, ocsp.Good)
+
+	var sawGET bool
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == http.MethodGet {
+			sawGET = true
+		}
+		w.Header().Set("Content-Type", "application/ocsp-response")
+		w.Write(respDER)
+	}))
+	defer server.Close()
+
+	_, err := fetchOCSP(ee, issuer, &Options{ResponderURL: server.URL, DisableGETPreference: true})
+	if err != nil {
+		t.Fatalf("fetchOCSP: %v", err)
+	}
+	if sawGET {
+		t.Error("did not expect a GET request when DisableGETPreference is set")
+	}
+}
@@ -0,0 +1,149 @@
+// Source is an OCSP response cache, consulted before a network
+// request and populated as fresh responses are fetched.
+package ocspchecker
+
+import (
+	"bytes"
+	"crypto/x509"
+	"encoding/base64"
+	"fmt"
+	"io/ioutil"
+	"math/big"
+	"strings"
+	"sync"
+	"time"
+
+	"golang.org/x/crypto/ocsp"
+)
+
+// Source caches raw DER OCSP responses keyed by certificate serial
+// number.
+type Source interface {
+	// Get returns the raw DER OCSP response cached for serial, if any.
+	Get(serial *big.Int) ([]byte, bool)
+	// Put stores the raw DER OCSP response for serial.
+	Put(serial *big.Int, response []byte)
+}
+
+// InMemorySource is a Source backed by a map. It is safe for
+// concurrent use, since a multi-responder query (see
+// ResponderPolicy) may call Put from more than one goroutine.
+type InMemorySource struct {
+	mu        sync.Mutex
+	responses map[string][]byte
+}
+
+// NewInMemorySource returns an empty InMemorySource.
+func NewInMemorySource() *InMemorySource {
+	return &InMemorySource{responses: make(map[string][]byte)}
+}
+
+func (s *InMemorySource) Get(serial *big.Int) ([]byte, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	resp, ok := s.responses[serial.String()]
+	return resp, ok
+}
+
+func (s *InMemorySource) Put(serial *big.Int, response []byte) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.responses[serial.String()] = response
+}
+
+// snapshot returns a copy of the cached responses, safe to range over
+// without holding s.mu.
+func (s *InMemorySource) snapshot() map[string][]byte {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	cp := make(map[string][]byte, len(s.responses))
+	for k, v := range s.responses {
+		cp[k] = v
+	}
+	return cp
+}
+
+// FileSource is a Source backed by a file of whitespace-separated
+// base64-encoded DER OCSP responses.
+type FileSource struct {
+	*InMemorySource
+	path string
+}
+
+// LoadFileSource reads path, parsing each whitespace-separated token
+// as a base64-encoded DER OCSP response signed by issuer, and returns
+// a FileSource seeded with the entries that parse successfully.
+// Entries that fail to decode or fail to verify against issuer are
+// skipped.
+func LoadFileSource(path string, issuer *x509.Certificate) (*FileSource, error) {
+	raw, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("ocspchecker: error reading source file: %v", err)
+	}
+
+	source := &FileSource{InMemorySource: NewInMemorySource(), path: path}
+
+	for _, tok := range strings.Fields(string(raw)) {
+		der, err := base64.StdEncoding.DecodeString(tok)
+		if err != nil {
+			continue
+		}
+		resp, err := ocsp.ParseResponse(der, issuer)
+		if err != nil {
+			continue
+		}
+		source.Put(resp.SerialNumber, der)
+	}
+
+	return source, nil
+}
+
+// Save writes every response cached by source back to its backing
+// file, base64-encoded and newline-separated.
+func (s *FileSource) Save() error {
+	var buf bytes.Buffer
+	for _, der := range s.snapshot() {
+		buf.WriteString(base64.StdEncoding.EncodeToString(der))
+		buf.WriteString("\n")
+	}
+	return ioutil.WriteFile(s.path, buf.Bytes(), 0644)
+}
+
+// Refresh re-fetches every response cached by source whose NextUpdate
+// falls within window of now, replacing the cached entry with the
+// fresh response. issuer must be the issuer that signed the cached
+// responses, and opts.ResponderURL must be set since the cached
+// entries do not carry the certificate's AuthorityInfoAccess. Refresh
+// writes fetched responses back into source itself, overriding any
+// opts.Source the caller set; callers of Refresh on a FileSource
+// should call Save afterward to persist the updated entries. Refresh
+// returns the fetch error for each serial that failed to refresh,
+// keyed by the serial's decimal string, or nil if every refresh
+// succeeded.
+func Refresh(source *InMemorySource, issuer *x509.Certificate, opts *Options, window time.Duration) map[string]error {
+	refreshOpts := Options{}
+	if opts != nil {
+		refreshOpts = *opts
+	}
+	refreshOpts.Source = source
+
+	now := time.Now()
+	errs := make(map[string]error)
+
+	for serial, der := range source.snapshot() {
+		resp, err := ocsp.ParseResponse(der, issuer)
+		if err != nil || resp.NextUpdate.IsZero() || resp.NextUpdate.Sub(now) > window {
+			continue
+		}
+
+		ee := &x509.Certificate{SerialNumber: resp.SerialNumber}
+		if _, err := fetchOCSPFresh(ee, issuer, &refreshOpts); err != nil {
+			errs[serial] = err
+		}
+	}
+
+	if len(errs) == 0 {
+		return nil
+	}
+	return errs
+}
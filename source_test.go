@@ -0,0 +1,219 @@
+package ocspchecker
+
+import (
+	"crypto/x509"
+	"encoding/base64"
+	"io/ioutil"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"golang.org/x/crypto/ocsp"
+)
+
+func TestInMemorySourceGetPut(t *testing.T) {
+	issuer, issuerKey, ee := generateTestChain(t)
+	der := signTestOCSPResponse(t, ee, issuer, issuerKey, ocsp.Good)
+
+	source := NewInMemorySource()
+	if _, ok := source.Get(ee.SerialNumber); ok {
+		t.Fatal("expected no cached response before Put")
+	}
+
+	source.Put(ee.SerialNumber, der)
+	got, ok := source.Get(ee.SerialNumber)
+	if !ok {
+		t.Fatal("expected a cached response after Put")
+	}
+	if string(got) != string(der) {
+		t.Error("Get did not return the response passed to Put")
+	}
+}
+
+func TestLoadFileSourceSkipsInvalidEntries(t *testing.T) {
+	issuer, issuerKey, ee := generateTestChain(t)
+	der := signTestOCSPResponse(t, ee, issuer, issuerKey, ocsp.Good)
+
+	dir := t.TempDir()
+	path := filepath.Join(dir, "responses")
+	contents := base64.StdEncoding.EncodeToString(der) + "\n" +
+		"not-valid-base64!!\n" +
+		base64.StdEncoding.EncodeToString([]byte("valid base64 but not an OCSP response")) + "\n"
+	if err := ioutil.WriteFile(path, []byte(contents), 0644); err != nil {
+		t.Fatalf("writing fixture file: %v", err)
+	}
+
+	source, err := LoadFileSource(path, issuer)
+	if err != nil {
+		t.Fatalf("LoadFileSource: %v", err)
+	}
+
+	got, ok := source.Get(ee.SerialNumber)
+	if !ok || string(got) != string(der) {
+		t.Error("expected the one valid entry to be loaded")
+	}
+	if len(source.responses) != 1 {
+		t.Errorf("got %d entries, want 1 (invalid entries should be skipped)", len(source.responses))
+	}
+}
+
+func TestFileSourceSaveRoundTrips(t *testing.T) {
+	issuer, issuerKey, ee := generateTestChain(t)
+	der := signTestOCSPResponse(t, ee, issuer, issuerKey, ocsp.Good)
+
+	dir := t.TempDir()
+	path := filepath.Join(dir, "responses")
+	source := &FileSource{InMemorySource: NewInMemorySource(), path: path}
+	source.Put(ee.SerialNumber, der)
+
+	if err := source.Save(); err != nil {
+		t.Fatalf("Save: %v", err)
+	}
+
+	reloaded, err := LoadFileSource(path, issuer)
+	if err != nil {
+		t.Fatalf("LoadFileSource after Save: %v", err)
+	}
+	got, ok := reloaded.Get(ee.SerialNumber)
+	if !ok || string(got) != string(der) {
+		t.Error("expected the saved response to reload unchanged")
+	}
+}
+
+func TestLoadFileSourceMissingFile(t *testing.T) {
+	issuer, _, _ := generateTestChain(t)
+	if _, err := LoadFileSource(filepath.Join(t.TempDir(), "missing"), issuer); err == nil {
+		t.Fatal("expected an error for a missing source file")
+	}
+}
+
+func TestCheckSourceHit(t *testing.T) {
+	issuer, issuerKey, ee := generateTestChain(t)
+	der := signTestOCSPResponse(t, ee, issuer, issuerKey, ocsp.Good)
+
+	source := NewInMemorySource()
+	source.Put(ee.SerialNumber, der)
+
+	opts := &Options{Source: source}
+	result, ok := checkSource(ee, issuer, opts)
+	if !ok {
+		t.Fatal("expected a cache hit")
+	}
+	if result.ResponderURL != "cache" {
+		t.Errorf("got ResponderURL %q, want %q", result.ResponderURL, "cache")
+	}
+	if result.Status != Good {
+		t.Errorf("got status %v, want Good", result.Status)
+	}
+}
+
+func TestCheckSourceMissOnStaleResponse(t *testing.T) {
+	issuer, issuerKey, ee := generateTestChain(t)
+
+	template := ocsp.Response{
+		Status:       ocsp.Good,
+		SerialNumber: ee.SerialNumber,
+		ThisUpdate:   time.Now().Add(-2 * time.Hour),
+		NextUpdate:   time.Now().Add(-time.Hour), // already expired
+	}
+	der, err := ocsp.CreateResponse(issuer, issuer, template, issuerKey)
+	if err != nil {
+		t.Fatalf("creating ocsp response: %v", err)
+	}
+
+	source := NewInMemorySource()
+	source.Put(ee.SerialNumber, der)
+
+	if _, ok := checkSource(ee, issuer, &Options{Source: source}); ok {
+		t.Error("expected a stale cached response to be treated as a cache miss")
+	}
+}
+
+func TestCheckSourceMissOnSerialMismatch(t *testing.T) {
+	issuer, issuerKey, ee := generateTestChain(t)
+	der := signTestOCSPResponse(t, ee, issuer, issuerKey, ocsp.Good)
+
+	source := NewInMemorySource()
+	source.Put(ee.SerialNumber, der)
+
+	other := &x509.Certificate{SerialNumber: big.NewInt(99)}
+	if _, ok := checkSource(other, issuer, &Options{Source: source}); ok {
+		t.Error("expected a lookup for a different serial to miss")
+	}
+}
+
+func TestRefresh(t *testing.T) {
+	issuer, issuerKey, ee := generateTestChain(t)
+	now := time.Now()
+
+	dueDER, err := ocsp.CreateResponse(issuer, issuer, ocsp.Response{
+		Status:       ocsp.Good,
+		SerialNumber: ee.SerialNumber,
+		ThisUpdate:   now.Add(-time.Hour),
+		NextUpdate:   now.Add(time.Minute), // inside the refresh window
+	}, issuerKey)
+	if err != nil {
+		t.Fatalf("creating due ocsp response: %v", err)
+	}
+
+	notDueSerial := big.NewInt(777)
+	notDueDER, err := ocsp.CreateResponse(issuer, issuer, ocsp.Response{
+		Status:       ocsp.Good,
+		SerialNumber: notDueSerial,
+		ThisUpdate:   now.Add(-time.Hour),
+		NextUpdate:   now.Add(10 * time.Hour), // outside the refresh window
+	}, issuerKey)
+	if err != nil {
+		t.Fatalf("creating not-due ocsp response: %v", err)
+	}
+
+	var refreshes int
+	refreshedNextUpdate := now.Add(2 * time.Hour)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		refreshes++
+		refreshedDER, err := ocsp.CreateResponse(issuer, issuer, ocsp.Response{
+			Status:       ocsp.Good,
+			SerialNumber: ee.SerialNumber,
+			ThisUpdate:   now,
+			NextUpdate:   refreshedNextUpdate,
+		}, issuerKey)
+		if err != nil {
+			t.Fatalf("creating refreshed ocsp response: %v", err)
+		}
+		w.Header().Set("Content-Type", "application/ocsp-response")
+		w.Write(refreshedDER)
+	}))
+	defer server.Close()
+
+	source := NewInMemorySource()
+	source.Put(ee.SerialNumber, dueDER)
+	source.Put(notDueSerial, notDueDER)
+
+	errs := Refresh(source, issuer, &Options{ResponderURL: server.URL}, 5*time.Minute)
+	if errs != nil {
+		t.Fatalf("Refresh: %v", errs)
+	}
+	if refreshes != 1 {
+		t.Fatalf("got %d network fetches, want 1 (only the due entry should refresh)", refreshes)
+	}
+
+	got, ok := source.Get(ee.SerialNumber)
+	if !ok {
+		t.Fatal("expected the due entry to still be cached")
+	}
+	refreshed, err := ocsp.ParseResponse(got, issuer)
+	if err != nil {
+		t.Fatalf("parsing refreshed response: %v", err)
+	}
+	if diff := refreshed.NextUpdate.Sub(refreshedNextUpdate); diff < -time.Second || diff > time.Second {
+		t.Errorf("got NextUpdate %v, want the refreshed %v", refreshed.NextUpdate, refreshedNextUpdate)
+	}
+
+	notDueGot, ok := source.Get(notDueSerial)
+	if !ok || string(notDueGot) != string(notDueDER) {
+		t.Error("expected the not-due entry to be left untouched")
+	}
+}
@@ -0,0 +1,37 @@
+// Parsing of the TLS Feature ("must-staple") certificate extension.
+package ocspchecker
+
+import (
+	"crypto/x509"
+	"encoding/asn1"
+)
+
+// tlsFeature is the OID for the TLS Feature extension (RFC 7633),
+// commonly known as "must-staple".
+var tlsFeature = asn1.ObjectIdentifier{1, 3, 6, 1, 5, 5, 7, 1, 24}
+
+// statusRequestFeature is the TLS extension ID for status_request
+// (RFC 6066 section 8), the feature value that signals must-staple.
+const statusRequestFeature = 5
+
+// RequiresStapling reports whether ee's TLS Feature extension lists
+// status_request, meaning ee requires a stapled OCSP response.
+func RequiresStapling(ee *x509.Certificate) bool {
+	for _, ext := range ee.Extensions {
+		if !ext.Id.Equal(tlsFeature) {
+			continue
+		}
+
+		var features []int
+		if _, err := asn1.Unmarshal(ext.Value, &features); err != nil {
+			return false
+		}
+
+		for _, f := range features {
+			if f == statusRequestFeature {
+				return true
+			}
+		}
+	}
+	return false
+}
@@ -0,0 +1,57 @@
+package ocspchecker
+
+import (
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/asn1"
+	"testing"
+)
+
+func buildTLSFeatureExtension(t *testing.T, features ...int) []byte {
+	t.Helper()
+	der, err := asn1.Marshal(features)
+	if err != nil {
+		t.Fatalf("asn1.Marshal: %v", err)
+	}
+	return der
+}
+
+func certWithTLSFeature(ext []byte) *x509.Certificate {
+	return &x509.Certificate{
+		Extensions: []pkix.Extension{
+			{Id: tlsFeature, Value: ext},
+		},
+	}
+}
+
+func TestRequiresStaplingStatusRequest(t *testing.T) {
+	ee := certWithTLSFeature(buildTLSFeatureExtension(t, statusRequestFeature))
+
+	if !RequiresStapling(ee) {
+		t.Error("expected RequiresStapling to report true for a status_request TLS Feature")
+	}
+}
+
+func TestRequiresStaplingOtherFeature(t *testing.T) {
+	ee := certWithTLSFeature(buildTLSFeatureExtension(t, 17)) // status_request_v2, not must-staple
+
+	if RequiresStapling(ee) {
+		t.Error("expected RequiresStapling to report false when status_request is not listed")
+	}
+}
+
+func TestRequiresStaplingNoExtension(t *testing.T) {
+	ee := &x509.Certificate{}
+
+	if RequiresStapling(ee) {
+		t.Error("expected RequiresStapling to report false when the TLS Feature extension is absent")
+	}
+}
+
+func TestRequiresStaplingMalformedExtension(t *testing.T) {
+	ee := certWithTLSFeature([]byte("not valid asn1"))
+
+	if RequiresStapling(ee) {
+		t.Error("expected RequiresStapling to report false for a malformed TLS Feature extension")
+	}
+}